@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthPolicy gates the mutating endpoints (/api/add, /api/remove,
+// /api/reset) behind either an HMAC signature over the request body or a
+// bearer JWT signed with an Ed25519 key, following the pattern used by
+// keepstore's signed locators and cc-metric-store's JWT middleware.
+// Read-only endpoints (/api/check, /api/stats) are public unless readToken
+// is set.
+type AuthPolicy struct {
+	enforcePermissions bool
+	hmacSecret         []byte
+	jwtPublicKey       ed25519.PublicKey
+	readToken          string
+
+	mu        sync.Mutex
+	tokenUses map[string]int
+	revoked   map[string]bool
+}
+
+// NewAuthPolicy creates an AuthPolicy. Pass a nil/empty hmacSecret or
+// jwtPublicKey to disable that verification method.
+func NewAuthPolicy(enforcePermissions bool, hmacSecret []byte, jwtPublicKey ed25519.PublicKey, readToken string) *AuthPolicy {
+	return &AuthPolicy{
+		enforcePermissions: enforcePermissions,
+		hmacSecret:         hmacSecret,
+		jwtPublicKey:       jwtPublicKey,
+		readToken:          readToken,
+		tokenUses:          make(map[string]int),
+		revoked:            make(map[string]bool),
+	}
+}
+
+// VerifySignature checks token against body (the raw request body, JSON or
+// binary), accepting either a hex HMAC-SHA256 signature over body (using
+// hmacSecret) or a bearer JWT signed with jwtPublicKey. It records a use
+// against the token for rate accounting and rejects revoked tokens.
+func (ap *AuthPolicy) VerifySignature(body []byte, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	ap.mu.Lock()
+	revoked := ap.revoked[token]
+	ap.mu.Unlock()
+	if revoked {
+		return false
+	}
+
+	ok := ap.verifyHMAC(body, token) || ap.verifyJWT(token)
+	if ok {
+		ap.mu.Lock()
+		ap.tokenUses[token]++
+		ap.mu.Unlock()
+	}
+	return ok
+}
+
+// verifyHMAC reports whether token is the hex-encoded HMAC-SHA256 of body
+// under hmacSecret.
+func (ap *AuthPolicy) verifyHMAC(body []byte, token string) bool {
+	if len(ap.hmacSecret) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, ap.hmacSecret)
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
+
+// verifyJWT reports whether token is a well-formed, unexpired JWT whose
+// signature verifies under jwtPublicKey using EdDSA (Ed25519).
+func (ap *AuthPolicy) verifyJWT(token string) bool {
+	if len(ap.jwtPublicKey) == 0 {
+		return false
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	if !ed25519.Verify(ap.jwtPublicKey, []byte(parts[0]+"."+parts[1]), signature) {
+		return false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return false
+	}
+	if claims.Exp != 0 && time.Now().Unix() > claims.Exp {
+		return false
+	}
+
+	return true
+}
+
+// Revoke marks a token so future VerifySignature calls reject it, letting
+// an operator cut off a compromised token without restarting the server.
+func (ap *AuthPolicy) Revoke(token string) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.revoked[token] = true
+}
+
+// Status summarizes the active policy for /status.json: whether
+// verification is enforced, which methods are configured, and per-token use
+// counts. Tokens are identified by hashTokenForStatus rather than their raw
+// value, since /status.json is diagnostic output and must not let a caller
+// harvest a live credential out of it.
+func (ap *AuthPolicy) Status() map[string]interface{} {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	tokenUses := make(map[string]int, len(ap.tokenUses))
+	for token, uses := range ap.tokenUses {
+		tokenUses[hashTokenForStatus(token)] = uses
+	}
+
+	return map[string]interface{}{
+		"enforcePermissions": ap.enforcePermissions,
+		"hmacConfigured":     len(ap.hmacSecret) > 0,
+		"jwtConfigured":      len(ap.jwtPublicKey) > 0,
+		"readTokenRequired":  ap.readToken != "",
+		"tokenUses":          tokenUses,
+		"revokedTokenCount":  len(ap.revoked),
+	}
+}
+
+// hashTokenForStatus returns a hex-encoded SHA-256 hash of token, used to
+// identify a token in /status.json output without exposing the token itself.
+func hashTokenForStatus(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// globalAuthPolicy is the process-wide auth policy. It defaults to not
+// enforcing anything, matching the original, open server behavior.
+var globalAuthPolicy = NewAuthPolicy(false, nil, nil, "")
+
+// requireWrite wraps a mutating handler so that, when enforcement is on, the
+// raw request body must carry a valid signature or bearer JWT. It works for
+// both JSON and binary (snapshot/merge) bodies, since verification runs
+// over the raw bytes rather than a parsed field.
+func requireWrite(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := authorizeWrite(w, r); !ok {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authorizeWrite re-reads and restores r.Body, then, if enforcement is on,
+// checks it against the bearer token. On failure it writes the error
+// response itself and returns ok=false. It is the shared implementation
+// behind requireWrite and the registry's dynamically-routed handlers, which
+// can't be wrapped with http.HandleFunc decorators since they dispatch on
+// URL path at runtime.
+func authorizeWrite(w http.ResponseWriter, r *http.Request) (body []byte, ok bool) {
+	body, err := readAndRestoreBody(r)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if !globalAuthPolicy.enforcePermissions {
+		return body, true
+	}
+
+	token := bearerToken(r)
+	if !globalAuthPolicy.VerifySignature(body, token) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	return body, true
+}
+
+// requireRead wraps a read-only handler so that, when a read token is
+// configured, the request must present it via the X-Bloom-Token header or an
+// "Authorization: Bearer <token>" header.
+func requireRead(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeRead(w, r) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authorizeRead reports whether the request carries a valid read token, when
+// one is configured. On failure it writes the error response itself and
+// returns false. It is the shared implementation behind requireRead and the
+// registry's dynamically-routed handlers, which can't be wrapped with
+// http.HandleFunc decorators since they dispatch on URL path at runtime.
+func authorizeRead(w http.ResponseWriter, r *http.Request) bool {
+	if globalAuthPolicy.readToken == "" {
+		return true
+	}
+
+	if subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(globalAuthPolicy.readToken)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+// bearerToken extracts a token from either the X-Bloom-Token header or a
+// standard "Authorization: Bearer <token>" header.
+func bearerToken(r *http.Request) string {
+	if t := r.Header.Get("X-Bloom-Token"); t != "" {
+		return t
+	}
+	auth := r.Header.Get("Authorization")
+	return strings.TrimPrefix(auth, "Bearer ")
+}
+
+// readAndRestoreBody reads r.Body and replaces it with a fresh reader over
+// the same bytes, so a later handler can still decode it after requireWrite
+// has inspected it.
+func readAndRestoreBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(globalAuthPolicy.Status())
+}
+
+// handleRevoke handles POST /api/revoke, revoking the token named in the
+// request body so VerifySignature rejects it on every future request. Like
+// any other mutating endpoint it is itself routed through requireWrite, so
+// revoking a token still requires presenting a valid signature or JWT.
+func handleRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	globalAuthPolicy.Revoke(req.Token)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
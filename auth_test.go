@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireWriteRejectsUnsignedRequest(t *testing.T) {
+	policy := NewAuthPolicy(true, []byte("secret"), nil, "")
+	orig := globalAuthPolicy
+	globalAuthPolicy = policy
+	defer func() { globalAuthPolicy = orig }()
+
+	called := false
+	handler := requireWrite(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	req := httptest.NewRequest(http.MethodPost, "/api/add", bytes.NewBufferString(`{"item":"x"}`))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if called {
+		t.Fatal("handler ran without a valid signature")
+	}
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+}
+
+func TestRequireWriteAcceptsValidHMAC(t *testing.T) {
+	secret := []byte("secret")
+	policy := NewAuthPolicy(true, secret, nil, "")
+	orig := globalAuthPolicy
+	globalAuthPolicy = policy
+	defer func() { globalAuthPolicy = orig }()
+
+	body := []byte(`{"item":"x"}`)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	token := hex.EncodeToString(mac.Sum(nil))
+
+	called := false
+	handler := requireWrite(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	req := httptest.NewRequest(http.MethodPost, "/api/add", bytes.NewReader(body))
+	req.Header.Set("X-Bloom-Token", token)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if !called {
+		t.Fatalf("expected handler to run, got status %d", rec.Code)
+	}
+}
+
+func TestRequireWriteGuardsBinaryBody(t *testing.T) {
+	secret := []byte("secret")
+	policy := NewAuthPolicy(true, secret, nil, "")
+	orig := globalAuthPolicy
+	globalAuthPolicy = policy
+	defer func() { globalAuthPolicy = orig }()
+
+	body := []byte{0x01, 0x02, 0x03, 0x04}
+	handler := requireWrite(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not run without a signature over the binary body")
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "/api/restore", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+}
+
+func TestVerifyJWTRejectsBadSignature(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := NewAuthPolicy(true, nil, pub, "")
+
+	if policy.VerifySignature([]byte("payload"), "not.a.jwt") {
+		t.Fatal("malformed JWT should not verify")
+	}
+}
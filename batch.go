@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// lineProtocolContentType is the Content-Type used for Influx-style line
+// protocol batches, matching cc-metric-store's write path.
+const lineProtocolContentType = "application/vnd.influx.line-protocol"
+
+// AddBatch adds every item in items, taking bf.mu.Lock() once for the whole
+// batch instead of once per item. Hashes are precomputed concurrently by a
+// worker pool sized by GOMAXPROCS to amortize the SHA-256 cost.
+func (bf *BloomFilter) AddBatch(items []string) {
+	hashes := bf.batchHashValues(items)
+
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+
+	for _, item := range hashes {
+		for _, hash := range item {
+			bf.setBit(hash)
+		}
+	}
+	bf.items += len(items)
+}
+
+// CheckBatch reports, for each item in items, whether it might be in the
+// filter. It takes bf.mu.RLock() once for the whole batch.
+func (bf *BloomFilter) CheckBatch(items []string) []bool {
+	hashes := bf.batchHashValues(items)
+
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+
+	results := make([]bool, len(items))
+	for i, item := range hashes {
+		found := true
+		for _, hash := range item {
+			if !bf.getBit(hash) {
+				found = false
+				break
+			}
+		}
+		results[i] = found
+	}
+	return results
+}
+
+// batchHashValues computes getHashValues(items[i]) for every item, spread
+// across a worker pool sized by GOMAXPROCS so the SHA-256 cost is amortized
+// across CPUs instead of serialized.
+func (bf *BloomFilter) batchHashValues(items []string) [][]uint {
+	results := make([][]uint, len(items))
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(items) {
+		workers = len(items)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	indexes := make(chan int)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = bf.getHashValues(items[i])
+			}
+		}()
+	}
+
+	for i := range items {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// packBits packs a []bool into 8-bits-per-byte form, most significant bit
+// first within each byte.
+func packBits(bits []bool) []byte {
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			packed[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+	return packed
+}
+
+// parseBatchItems reads items from the request body, accepting either a
+// JSON array body {"items": [...]}, or (when Content-Type is
+// lineProtocolContentType) Influx-style line protocol, one point per line,
+// using the measurement name (the text before the first comma or space) as
+// the item.
+func parseBatchItems(r *http.Request) ([]string, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), lineProtocolContentType) {
+		var items []string
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			items = append(items, measurementName(line))
+		}
+		return items, scanner.Err()
+	}
+
+	var req struct {
+		Items []string `json:"items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+	return req.Items, nil
+}
+
+// measurementName extracts the measurement name (the text before the first
+// unescaped comma or space) from a line protocol point, unescaping any
+// backslash-escaped comma or space along the way so "cpu\,east load=1"
+// yields "cpu,east" rather than splitting at the escaped comma.
+func measurementName(line string) string {
+	var name strings.Builder
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' && i+1 < len(line) && (line[i+1] == ',' || line[i+1] == ' ') {
+			name.WriteByte(line[i+1])
+			i++
+			continue
+		}
+		if c == ',' || c == ' ' {
+			break
+		}
+		name.WriteByte(c)
+	}
+	return name.String()
+}
+
+func handleAddBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	items, err := parseBatchItems(r)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	switch activeFilterMode {
+	case modeCounting:
+		for _, item := range items {
+			countingBloomFilter.Add(item)
+		}
+	case modeScalable:
+		for _, item := range items {
+			globalScalableFilter.Add(item)
+		}
+	default:
+		defaultFilter().AddBatch(items)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "count": len(items)})
+}
+
+// handleCheckBatch checks a batch of items and returns either a parallel
+// boolean array (default) or, with ?format=bits, a bit-packed base64 string
+// so a client can probe thousands of keys in one compact round-trip.
+func handleCheckBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	items, err := parseBatchItems(r)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	var results []bool
+	switch activeFilterMode {
+	case modeCounting:
+		results = make([]bool, len(items))
+		for i, item := range items {
+			results[i] = countingBloomFilter.Contains(item)
+		}
+	case modeScalable:
+		results = make([]bool, len(items))
+		for i, item := range items {
+			results[i] = globalScalableFilter.Contains(item)
+		}
+	default:
+		results = defaultFilter().CheckBatch(items)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("format") == "bits" {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"count": len(results),
+			"bits":  base64.StdEncoding.EncodeToString(packBits(results)),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"exists": results})
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/binary"
+	"flag"
 	"fmt"
 	"log"
 	"math"
+	"math/bits"
 	"net/http"
 	"encoding/json"
 	"sync"
@@ -13,33 +17,57 @@ import (
 
 // BloomFilter represents a Bloom filter
 type BloomFilter struct {
-	m     uint      // size of bit array
-	k     uint      // number of hash functions
-	bits  []bool    // bit array
+	m     uint         // size of bit array
+	k     uint         // number of hash functions
+	bits  []uint64     // bit array, packed 64 bits per word
 	mu    sync.RWMutex // for thread safety
-	items int       // count of items added
+	items int          // count of items added
 }
 
 // NewBloomFilter creates a new Bloom filter with optimal size and hash count
 func NewBloomFilter(expectedItems int, falsePositiveRate float64) *BloomFilter {
 	m := uint(math.Ceil(-float64(expectedItems) * math.Log(falsePositiveRate) / math.Pow(math.Log(2), 2)))
 	k := uint(math.Ceil(math.Log(2) * float64(m) / float64(expectedItems)))
-	
+
 	return &BloomFilter{
 		m:    m,
 		k:    k,
-		bits: make([]bool, m),
+		bits: make([]uint64, wordsFor(m)),
+	}
+}
+
+// wordsFor returns the number of uint64 words needed to pack m bits.
+func wordsFor(m uint) uint {
+	return (m + 63) / 64
+}
+
+// setBit sets bit i of the packed bit array.
+func (bf *BloomFilter) setBit(i uint) {
+	bf.bits[i/64] |= 1 << (i % 64)
+}
+
+// getBit reports whether bit i of the packed bit array is set.
+func (bf *BloomFilter) getBit(i uint) bool {
+	return bf.bits[i/64]&(1<<(i%64)) != 0
+}
+
+// countSetBits returns the number of set bits across the packed bit array.
+func (bf *BloomFilter) countSetBits() int {
+	setBits := 0
+	for _, word := range bf.bits {
+		setBits += bits.OnesCount64(word)
 	}
+	return setBits
 }
 
 // Add adds an item to the Bloom filter
 func (bf *BloomFilter) Add(item string) {
 	bf.mu.Lock()
 	defer bf.mu.Unlock()
-	
+
 	hashes := bf.getHashValues(item)
 	for _, hash := range hashes {
-		bf.bits[hash] = true
+		bf.setBit(hash)
 	}
 	bf.items++
 }
@@ -48,10 +76,10 @@ func (bf *BloomFilter) Add(item string) {
 func (bf *BloomFilter) Contains(item string) bool {
 	bf.mu.RLock()
 	defer bf.mu.RUnlock()
-	
+
 	hashes := bf.getHashValues(item)
 	for _, hash := range hashes {
-		if !bf.bits[hash] {
+		if !bf.getBit(hash) {
 			return false
 		}
 	}
@@ -62,18 +90,13 @@ func (bf *BloomFilter) Contains(item string) bool {
 func (bf *BloomFilter) Stats() map[string]interface{} {
 	bf.mu.RLock()
 	defer bf.mu.RUnlock()
-	
-	setBits := 0
-	for _, bit := range bf.bits {
-		if bit {
-			setBits++
-		}
-	}
-	
+
+	setBits := bf.countSetBits()
+
 	// Calculate fill ratio and estimated false positive rate
 	fillRatio := float64(setBits) / float64(bf.m)
 	falsePositiveRate := math.Pow(fillRatio, float64(bf.k))
-	
+
 	return map[string]interface{}{
 		"size":                bf.m,
 		"hashFunctions":       bf.k,
@@ -88,8 +111,8 @@ func (bf *BloomFilter) Stats() map[string]interface{} {
 func (bf *BloomFilter) Reset() {
 	bf.mu.Lock()
 	defer bf.mu.Unlock()
-	
-	bf.bits = make([]bool, bf.m)
+
+	bf.bits = make([]uint64, wordsFor(bf.m))
 	bf.items = 0
 }
 
@@ -109,15 +132,53 @@ func (bf *BloomFilter) getHashValues(item string) []uint {
 	return result
 }
 
-// Global bloom filter instance with default size
-var globalBloomFilter = NewBloomFilter(10000, 0.01)
-
 func main() {
-	http.HandleFunc("/api/add", handleAdd)
-	http.HandleFunc("/api/check", handleCheck)
-	http.HandleFunc("/api/stats", handleStats)
-	http.HandleFunc("/api/reset", handleReset)
-	
+	mode := flag.String("mode", "classic", "bloom filter mode: classic, counting or scalable")
+	enforcePermissions := flag.Bool("enforce-permissions", false, "require a signature or JWT on mutating endpoints")
+	hmacSecret := flag.String("hmac-secret", "", "shared secret for HMAC-SHA256 request signing")
+	jwtPublicKey := flag.String("jwt-public-key", "", "base64-encoded Ed25519 public key for JWT verification")
+	readToken := flag.String("read-token", "", "if set, required to reach read-only endpoints")
+	flag.Parse()
+
+	switch *mode {
+	case "counting":
+		activeFilterMode = modeCounting
+		countingBloomFilter = NewCountingBloomFilter(10000, 0.01)
+	case "scalable":
+		activeFilterMode = modeScalable
+	}
+
+	var pubKey ed25519.PublicKey
+	if *jwtPublicKey != "" {
+		decoded, err := base64.StdEncoding.DecodeString(*jwtPublicKey)
+		if err != nil {
+			log.Fatalf("invalid -jwt-public-key: %v", err)
+		}
+		pubKey = ed25519.PublicKey(decoded)
+	}
+	globalAuthPolicy = NewAuthPolicy(*enforcePermissions, []byte(*hmacSecret), pubKey, *readToken)
+
+	http.HandleFunc("/api/add", requireWrite(handleAdd))
+	http.HandleFunc("/api/check", requireRead(handleCheck))
+	http.HandleFunc("/api/stats", requireRead(handleStats))
+	http.HandleFunc("/api/reset", requireWrite(handleReset))
+	http.HandleFunc("/api/mipmap/add", requireWrite(handleMipmapAdd))
+	http.HandleFunc("/api/mipmap/query", requireRead(handleMipmapQuery))
+	http.HandleFunc("/api/mipmap/snapshot", requireRead(handleMipmapSnapshot))
+	http.HandleFunc("/api/mipmap/restore", requireWrite(handleMipmapRestore))
+	http.HandleFunc("/api/remove", requireWrite(handleRemove))
+	http.HandleFunc("/api/untrash", requireWrite(handleUntrash))
+	http.HandleFunc("/api/addBatch", requireWrite(handleAddBatch))
+	http.HandleFunc("/api/checkBatch", requireRead(handleCheckBatch))
+	http.HandleFunc("/api/snapshot", requireRead(handleSnapshot))
+	http.HandleFunc("/api/restore", requireWrite(handleRestore))
+	http.HandleFunc("/api/merge", requireWrite(handleMerge))
+	http.HandleFunc("/api/revoke", requireWrite(handleRevoke))
+	http.HandleFunc("/api/", handleNamedFilter)
+	http.HandleFunc("/index", requireRead(handleIndex))
+	http.HandleFunc("/index/", requireRead(handleIndex))
+	http.HandleFunc("/status.json", requireRead(handleStatus))
+
 	fmt.Println("Bloom filter server running on :8081")
 	log.Fatal(http.ListenAndServe(":8081", nil))
 }
@@ -138,8 +199,15 @@ func handleAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	globalBloomFilter.Add(req.Item)
-	
+	switch activeFilterMode {
+	case modeCounting:
+		countingBloomFilter.Add(req.Item)
+	case modeScalable:
+		globalScalableFilter.Add(req.Item)
+	default:
+		defaultFilter().Add(req.Item)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]bool{"success": true})
 }
@@ -160,8 +228,16 @@ func handleCheck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	contains := globalBloomFilter.Contains(req.Item)
-	
+	var contains bool
+	switch activeFilterMode {
+	case modeCounting:
+		contains = countingBloomFilter.Contains(req.Item)
+	case modeScalable:
+		contains = globalScalableFilter.Contains(req.Item)
+	default:
+		contains = defaultFilter().Contains(req.Item)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]bool{"exists": contains})
 }
@@ -171,9 +247,17 @@ func handleStats(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	
-	stats := globalBloomFilter.Stats()
-	
+
+	var stats map[string]interface{}
+	switch activeFilterMode {
+	case modeCounting:
+		stats = countingBloomFilter.Stats()
+	case modeScalable:
+		stats = globalScalableFilter.Stats()
+	default:
+		stats = defaultFilter().Stats()
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
 }
@@ -184,7 +268,14 @@ func handleReset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	globalBloomFilter.Reset()
+	switch activeFilterMode {
+	case modeCounting:
+		countingBloomFilter.Reset()
+	case modeScalable:
+		globalScalableFilter.Reset()
+	default:
+		defaultFilter().Reset()
+	}
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]bool{"success": true})
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sync"
+)
+
+// maxCounter is the saturation point for a counting Bloom filter's counters.
+// A uint8 counter that would overflow past this value is simply left at it,
+// which avoids wraparound bugs at the cost that Remove never decrements a
+// saturated counter again, so it stays set until Reset.
+const maxCounter = math.MaxUint8
+
+// CountingBloomFilter is a Bloom filter variant that replaces the bit array
+// with per-slot counters, so an item can be removed again via Remove without
+// disturbing other items that happen to share a slot.
+type CountingBloomFilter struct {
+	m        uint
+	k        uint
+	counters []uint8
+	mu       sync.RWMutex
+	items    int
+}
+
+// NewCountingBloomFilter creates a new counting Bloom filter with optimal
+// size and hash count for the given expected item count and false positive
+// rate.
+func NewCountingBloomFilter(expectedItems int, falsePositiveRate float64) *CountingBloomFilter {
+	m := uint(math.Ceil(-float64(expectedItems) * math.Log(falsePositiveRate) / math.Pow(math.Log(2), 2)))
+	k := uint(math.Ceil(math.Log(2) * float64(m) / float64(expectedItems)))
+
+	return &CountingBloomFilter{
+		m:        m,
+		k:        k,
+		counters: make([]uint8, m),
+	}
+}
+
+// Add adds an item to the counting Bloom filter, incrementing each of its k
+// counters (saturating at maxCounter).
+func (cbf *CountingBloomFilter) Add(item string) {
+	cbf.mu.Lock()
+	defer cbf.mu.Unlock()
+
+	for _, hash := range cbf.getHashValues(item) {
+		if cbf.counters[hash] < maxCounter {
+			cbf.counters[hash]++
+		}
+	}
+	cbf.items++
+}
+
+// Remove decrements the counters for item, undoing a prior Add. Counters
+// that are already saturated are left at maxCounter, since a saturated
+// counter may be backing other items that haven't been removed yet.
+func (cbf *CountingBloomFilter) Remove(item string) {
+	cbf.mu.Lock()
+	defer cbf.mu.Unlock()
+
+	for _, hash := range cbf.getHashValues(item) {
+		if cbf.counters[hash] > 0 && cbf.counters[hash] < maxCounter {
+			cbf.counters[hash]--
+		}
+	}
+	if cbf.items > 0 {
+		cbf.items--
+	}
+}
+
+// Untrash re-increments the counters for item, the exact inverse of Remove,
+// so an operator can restore an item that was removed by mistake. Counters
+// that are already saturated are left at maxCounter, the same saturation
+// behavior as Add.
+func (cbf *CountingBloomFilter) Untrash(item string) {
+	cbf.mu.Lock()
+	defer cbf.mu.Unlock()
+
+	for _, hash := range cbf.getHashValues(item) {
+		if cbf.counters[hash] < maxCounter {
+			cbf.counters[hash]++
+		}
+	}
+	cbf.items++
+}
+
+// Contains checks if an item might be in the counting Bloom filter.
+func (cbf *CountingBloomFilter) Contains(item string) bool {
+	cbf.mu.RLock()
+	defer cbf.mu.RUnlock()
+
+	for _, hash := range cbf.getHashValues(item) {
+		if cbf.counters[hash] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Stats returns statistics about the counting Bloom filter, including
+// counter saturation and an estimate of deletion accuracy: the fraction of
+// set counters that are not yet saturated, since Remove never decrements a
+// saturated counter back towards zero.
+func (cbf *CountingBloomFilter) Stats() map[string]interface{} {
+	cbf.mu.RLock()
+	defer cbf.mu.RUnlock()
+
+	setCounters := 0
+	saturatedCounters := 0
+	for _, c := range cbf.counters {
+		if c > 0 {
+			setCounters++
+		}
+		if c == maxCounter {
+			saturatedCounters++
+		}
+	}
+
+	fillRatio := float64(setCounters) / float64(cbf.m)
+	falsePositiveRate := math.Pow(fillRatio, float64(cbf.k))
+
+	deletionAccuracy := 1.0
+	if setCounters > 0 {
+		deletionAccuracy = 1.0 - float64(saturatedCounters)/float64(setCounters)
+	}
+
+	return map[string]interface{}{
+		"size":              cbf.m,
+		"hashFunctions":     cbf.k,
+		"itemsAdded":        cbf.items,
+		"countersSet":       setCounters,
+		"fillRatio":         fillRatio,
+		"falsePositiveRate": falsePositiveRate,
+		"saturatedCounters": saturatedCounters,
+		"deletionAccuracy":  deletionAccuracy,
+	}
+}
+
+// Reset clears the counting Bloom filter.
+func (cbf *CountingBloomFilter) Reset() {
+	cbf.mu.Lock()
+	defer cbf.mu.Unlock()
+
+	cbf.counters = make([]uint8, cbf.m)
+	cbf.items = 0
+}
+
+// getHashValues generates k hash values for an item, using the same scheme
+// as BloomFilter.getHashValues.
+func (cbf *CountingBloomFilter) getHashValues(item string) []uint {
+	bf := &BloomFilter{m: cbf.m, k: cbf.k}
+	return bf.getHashValues(item)
+}
+
+// countingBloomFilter is the process-wide counting Bloom filter, used when
+// the server is started in counting mode. It is only non-nil when
+// activeFilterMode == modeCounting.
+var countingBloomFilter *CountingBloomFilter
+
+// filterMode selects whether the server runs classic (packed bit array),
+// counting or scalable Bloom filter semantics. Classic mode is the default, matching the
+// original single-filter behavior.
+type filterMode int
+
+const (
+	modeClassic filterMode = iota
+	modeCounting
+	modeScalable
+)
+
+var activeFilterMode = modeClassic
+
+func handleRemove(w http.ResponseWriter, r *http.Request) {
+	mutateCounting(w, r, (*CountingBloomFilter).Remove)
+}
+
+// handleUntrash reverts a prior Remove by re-incrementing the item's
+// counters, mirroring the keepstore trash/untrash pattern: Remove acts as a
+// soft delete and Untrash restores exactly what it took away.
+func handleUntrash(w http.ResponseWriter, r *http.Request) {
+	mutateCounting(w, r, (*CountingBloomFilter).Untrash)
+}
+
+// mutateCounting decodes {"item": ...} and applies op to it against the
+// counting Bloom filter, backing both handleRemove and handleUntrash.
+func mutateCounting(w http.ResponseWriter, r *http.Request, op func(*CountingBloomFilter, string)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if activeFilterMode != modeCounting {
+		http.Error(w, "only supported in counting mode", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Item string `json:"item"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	op(countingBloomFilter, req.Item)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// mipmapLevels defines the bucket granularities maintained by a
+// MipmapBloomFilter, from finest to coarsest. Modeled after go-ethereum's
+// MipmapBloom log index, where coarser levels let range queries skip over
+// buckets that can't possibly contain a match.
+var mipmapLevels = []uint64{1, 10, 100, 1000}
+
+// MipmapBloomFilter maintains a stack of Bloom filters keyed by numeric
+// bucket at multiple granularities, so a range query over a large sequence
+// space can start at the coarsest level and only recurse into finer levels
+// where a hit is possible.
+type MipmapBloomFilter struct {
+	mu      sync.RWMutex
+	levels  []uint64
+	filters map[uint64]map[uint64]*BloomFilter // level -> bucket -> filter
+
+	expectedItems     int
+	falsePositiveRate float64
+}
+
+// NewMipmapBloomFilter creates a mipmap Bloom filter using the given levels
+// (e.g. mipmapLevels) and the expected-items/false-positive-rate used to size
+// each per-bucket Bloom filter.
+func NewMipmapBloomFilter(levels []uint64, expectedItems int, falsePositiveRate float64) *MipmapBloomFilter {
+	filters := make(map[uint64]map[uint64]*BloomFilter, len(levels))
+	for _, level := range levels {
+		filters[level] = make(map[uint64]*BloomFilter)
+	}
+
+	return &MipmapBloomFilter{
+		levels:            levels,
+		filters:           filters,
+		expectedItems:     expectedItems,
+		falsePositiveRate: falsePositiveRate,
+	}
+}
+
+// Add inserts item into the bucket at each level that contains seq, where
+// bucket = seq / level.
+func (mbf *MipmapBloomFilter) Add(item string, seq uint64) {
+	mbf.mu.Lock()
+	defer mbf.mu.Unlock()
+
+	for _, level := range mbf.levels {
+		bucket := seq / level
+		mbf.bucketFilterLocked(level, bucket).Add(item)
+	}
+}
+
+// bucketFilterLocked returns the Bloom filter for a level/bucket pair,
+// creating it on first use. Callers must hold mbf.mu.
+func (mbf *MipmapBloomFilter) bucketFilterLocked(level, bucket uint64) *BloomFilter {
+	buckets := mbf.filters[level]
+	bf, ok := buckets[bucket]
+	if !ok {
+		bf = NewBloomFilter(mbf.expectedItems, mbf.falsePositiveRate)
+		buckets[bucket] = bf
+	}
+	return bf
+}
+
+// QueryRange walks the coarsest level whose buckets fully cover [fromSeq,
+// toSeq], recursing into finer levels only for buckets that report a
+// possible hit. A negative at any level guarantees item is absent from every
+// child bucket, so whole ranges get skipped. It returns the candidate
+// buckets (expressed in seq terms, i.e. level*bucket) at the finest level
+// reached.
+func (mbf *MipmapBloomFilter) QueryRange(item string, fromSeq, toSeq uint64) []uint64 {
+	if fromSeq > toSeq {
+		return nil
+	}
+
+	mbf.mu.RLock()
+	defer mbf.mu.RUnlock()
+
+	// Pick the coarsest level whose bucket width fully fits within the range,
+	// starting from the coarsest level and stepping down until one fits.
+	startLevel := len(mbf.levels) - 1
+	for startLevel > 0 && mbf.levels[startLevel] > (toSeq-fromSeq+1) {
+		startLevel--
+	}
+
+	return mbf.queryLevel(item, startLevel, fromSeq, toSeq)
+}
+
+// queryLevel checks every bucket of mbf.levels[levelIdx] overlapping
+// [fromSeq, toSeq] and recurses into the next finer level for any bucket
+// that might contain item.
+func (mbf *MipmapBloomFilter) queryLevel(item string, levelIdx int, fromSeq, toSeq uint64) []uint64 {
+	level := mbf.levels[levelIdx]
+	firstBucket := fromSeq / level
+	lastBucket := toSeq / level
+
+	var candidates []uint64
+	for bucket := firstBucket; bucket <= lastBucket; bucket++ {
+		bf, ok := mbf.filters[level][bucket]
+		if !ok || !bf.Contains(item) {
+			continue
+		}
+
+		if levelIdx == 0 {
+			candidates = append(candidates, bucket*level)
+			continue
+		}
+
+		childFrom := bucket * level
+		childTo := childFrom + level - 1
+		if childFrom < fromSeq {
+			childFrom = fromSeq
+		}
+		if childTo > toSeq {
+			childTo = toSeq
+		}
+		candidates = append(candidates, mbf.queryLevel(item, levelIdx-1, childFrom, childTo)...)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i] < candidates[j] })
+	return candidates
+}
+
+// mipmapSnapshotVersion is the wire format version written by
+// MipmapBloomFilter.MarshalBinary.
+const mipmapSnapshotVersion = 1
+
+// MarshalBinary serializes the mipmap filter's levels and populated
+// per-bucket filters into a single versioned blob, reusing
+// BloomFilter.MarshalBinary for each bucket so the per-level bit arrays
+// persist in the same compact format as a standalone snapshot. The layout
+// is: 1-byte version, level count + levels, expectedItems,
+// falsePositiveRate, bucket count, then for each bucket its level, bucket
+// index, and length-prefixed BloomFilter blob.
+func (mbf *MipmapBloomFilter) MarshalBinary() ([]byte, error) {
+	mbf.mu.RLock()
+	defer mbf.mu.RUnlock()
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(mipmapSnapshotVersion)
+
+	if err := binary.Write(buf, binary.LittleEndian, uint64(len(mbf.levels))); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(buf, binary.LittleEndian, mbf.levels); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(buf, binary.LittleEndian, uint64(mbf.expectedItems)); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(buf, binary.LittleEndian, mbf.falsePositiveRate); err != nil {
+		return nil, err
+	}
+
+	type entry struct {
+		level, bucket uint64
+		blob          []byte
+	}
+	var entries []entry
+	for level, buckets := range mbf.filters {
+		for bucket, bf := range buckets {
+			blob, err := bf.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry{level, bucket, blob})
+		}
+	}
+
+	if err := binary.Write(buf, binary.LittleEndian, uint64(len(entries))); err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if err := binary.Write(buf, binary.LittleEndian, e.level); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(buf, binary.LittleEndian, e.bucket); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(buf, binary.LittleEndian, uint64(len(e.blob))); err != nil {
+			return nil, err
+		}
+		buf.Write(e.blob)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the mipmap filter's state with the snapshot
+// produced by MarshalBinary. Every length read off the wire is bound-checked
+// against the bytes actually remaining before it is used to allocate or
+// slice, since a crafted snapshot is otherwise an easy way to crash the
+// process (see the equivalent BloomFilter.UnmarshalBinary fix).
+func (mbf *MipmapBloomFilter) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+
+	var version uint8
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return err
+	}
+	if version != mipmapSnapshotVersion {
+		return fmt.Errorf("mipmap: unsupported snapshot version %d", version)
+	}
+
+	var levelCount uint64
+	if err := binary.Read(r, binary.LittleEndian, &levelCount); err != nil {
+		return err
+	}
+	if levelCount > uint64(r.Len())/8 {
+		return fmt.Errorf("mipmap: snapshot declares %d levels but only %d bytes remain", levelCount, r.Len())
+	}
+	levels := make([]uint64, levelCount)
+	if err := binary.Read(r, binary.LittleEndian, levels); err != nil {
+		return err
+	}
+
+	var expectedItems uint64
+	var falsePositiveRate float64
+	if err := binary.Read(r, binary.LittleEndian, &expectedItems); err != nil {
+		return err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &falsePositiveRate); err != nil {
+		return err
+	}
+
+	var entryCount uint64
+	if err := binary.Read(r, binary.LittleEndian, &entryCount); err != nil {
+		return err
+	}
+	// Each entry is at least 3 uint64s (level, bucket, blob length), so this
+	// bound rejects a corrupt/hostile count before the loop below even
+	// starts allocating per-bucket filters.
+	if entryCount > uint64(r.Len())/24 {
+		return fmt.Errorf("mipmap: snapshot declares %d entries but only %d bytes remain", entryCount, r.Len())
+	}
+
+	filters := make(map[uint64]map[uint64]*BloomFilter, len(levels))
+	for _, level := range levels {
+		filters[level] = make(map[uint64]*BloomFilter)
+	}
+
+	for i := uint64(0); i < entryCount; i++ {
+		var level, bucket, blobLen uint64
+		if err := binary.Read(r, binary.LittleEndian, &level); err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &bucket); err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &blobLen); err != nil {
+			return err
+		}
+		if blobLen > uint64(r.Len()) {
+			return fmt.Errorf("mipmap: entry %d declares %d blob bytes but only %d remain", i, blobLen, r.Len())
+		}
+
+		blob := make([]byte, blobLen)
+		if _, err := io.ReadFull(r, blob); err != nil {
+			return err
+		}
+
+		bf := &BloomFilter{}
+		if err := bf.UnmarshalBinary(blob); err != nil {
+			return err
+		}
+
+		if _, ok := filters[level]; !ok {
+			filters[level] = make(map[uint64]*BloomFilter)
+		}
+		filters[level][bucket] = bf
+	}
+
+	mbf.mu.Lock()
+	defer mbf.mu.Unlock()
+
+	mbf.levels = levels
+	mbf.filters = filters
+	mbf.expectedItems = int(expectedItems)
+	mbf.falsePositiveRate = falsePositiveRate
+
+	return nil
+}
+
+// Global mipmap bloom filter instance, sized the same as the registry's
+// default filter.
+var globalMipmapFilter = NewMipmapBloomFilter(mipmapLevels, 10000, 0.01)
+
+func handleMipmapAdd(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Item string `json:"item"`
+		Seq  uint64 `json:"seq"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	globalMipmapFilter.Add(req.Item, req.Seq)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// handleMipmapSnapshot returns the global mipmap filter as a binary blob,
+// with a SHA-256 checksum of the payload in the X-Checksum-Sha256 header,
+// mirroring handleSnapshot.
+func handleMipmapSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	blob, err := globalMipmapFilter.MarshalBinary()
+	if err != nil {
+		http.Error(w, "Failed to snapshot filter", http.StatusInternalServerError)
+		return
+	}
+	checksum := sha256.Sum256(blob)
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Checksum-Sha256", hex.EncodeToString(checksum[:]))
+	w.Write(blob)
+}
+
+// handleMipmapRestore replaces the global mipmap filter's state with the
+// uploaded snapshot, mirroring handleRestore.
+func handleMipmapRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := globalMipmapFilter.UnmarshalBinary(body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"success":true}`)
+}
+
+func handleMipmapQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Item    string `json:"item"`
+		FromSeq uint64 `json:"fromSeq"`
+		ToSeq   uint64 `json:"toSeq"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	buckets := globalMipmapFilter.QueryRange(req.Item, req.FromSeq, req.ToSeq)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"buckets": buckets})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryRangeFindsItemAcrossLevels(t *testing.T) {
+	mbf := NewMipmapBloomFilter(mipmapLevels, 1000, 0.01)
+	mbf.Add("a", 5)
+	mbf.Add("b", 250)
+	mbf.Add("c", 1500)
+
+	// A narrow range around "a" should resolve down to the exact seq.
+	if got := mbf.QueryRange("a", 0, 9); !reflect.DeepEqual(got, []uint64{5}) {
+		t.Fatalf("QueryRange(a, 0, 9) = %v, want [5]", got)
+	}
+
+	// A wide range spanning multiple coarse buckets should still recurse
+	// down to "b"'s exact seq, and must not also report "c" (out of range).
+	if got := mbf.QueryRange("b", 0, 999); !reflect.DeepEqual(got, []uint64{250}) {
+		t.Fatalf("QueryRange(b, 0, 999) = %v, want [250]", got)
+	}
+
+	// A range that doesn't contain any added seq for the item should come
+	// back empty even though the item exists elsewhere in the filter.
+	if got := mbf.QueryRange("c", 0, 999); len(got) != 0 {
+		t.Fatalf("QueryRange(c, 0, 999) = %v, want none", got)
+	}
+}
+
+func TestQueryRangeBoundaries(t *testing.T) {
+	mbf := NewMipmapBloomFilter(mipmapLevels, 1000, 0.01)
+	mbf.Add("edge", 999)
+	mbf.Add("edge", 1000)
+
+	// [0, 999] must include the seq at the top of the range...
+	if got := mbf.QueryRange("edge", 0, 999); !reflect.DeepEqual(got, []uint64{999}) {
+		t.Fatalf("QueryRange(edge, 0, 999) = %v, want [999]", got)
+	}
+	// ...and [1000, 1999] must include the seq at the bottom, not the one
+	// just below it.
+	if got := mbf.QueryRange("edge", 1000, 1999); !reflect.DeepEqual(got, []uint64{1000}) {
+		t.Fatalf("QueryRange(edge, 1000, 1999) = %v, want [1000]", got)
+	}
+}
+
+func TestQueryRangeEmptyWhenFromAfterTo(t *testing.T) {
+	mbf := NewMipmapBloomFilter(mipmapLevels, 1000, 0.01)
+	mbf.Add("a", 5)
+
+	if got := mbf.QueryRange("a", 10, 5); got != nil {
+		t.Fatalf("QueryRange with fromSeq > toSeq = %v, want nil", got)
+	}
+}
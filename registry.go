@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// defaultFilterName is the name of the filter backing the original
+// unnamed /api/add, /api/check, /api/stats and /api/reset endpoints, so
+// they are just a thin alias over the registry entry named "default"
+// rather than a second, parallel filter instance.
+const defaultFilterName = "default"
+
+// Registry holds named Bloom filters, so the server hosts many
+// independently-configured filters instead of a single global one. Each
+// named filter is created on demand via POST /api/{name} with its own
+// expected-items/false-positive-rate, and can be looked up or removed by
+// name. /api/add, /api/check, /api/stats and /api/reset operate on the
+// registry entry named defaultFilterName; named filters are reached
+// through /api/{name}/....
+type Registry struct {
+	mu      sync.RWMutex
+	filters map[string]*BloomFilter
+}
+
+// NewRegistry creates an empty filter registry.
+func NewRegistry() *Registry {
+	return &Registry{filters: make(map[string]*BloomFilter)}
+}
+
+// Create adds a new named filter, replacing any existing filter under that
+// name.
+func (reg *Registry) Create(name string, expectedItems int, falsePositiveRate float64) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	reg.filters[name] = NewBloomFilter(expectedItems, falsePositiveRate)
+}
+
+// Get returns the named filter, or nil if it doesn't exist.
+func (reg *Registry) Get(name string) *BloomFilter {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	return reg.filters[name]
+}
+
+// Delete removes the named filter. It is a no-op if the name doesn't exist.
+func (reg *Registry) Delete(name string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	delete(reg.filters, name)
+}
+
+// Names returns the names of every filter whose name starts with prefix, in
+// no particular order. An empty prefix matches every name.
+func (reg *Registry) Names(prefix string) []string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	var names []string
+	for name := range reg.filters {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// globalRegistry is the process-wide set of named filters, seeded with the
+// default filter that backs the unnamed /api/* endpoints.
+var globalRegistry = newRegistryWithDefault()
+
+func newRegistryWithDefault() *Registry {
+	reg := NewRegistry()
+	reg.Create(defaultFilterName, 10000, 0.01)
+	return reg
+}
+
+// defaultFilter returns the registry entry backing the unnamed /api/add,
+// /api/check, /api/stats and /api/reset endpoints.
+func defaultFilter() *BloomFilter {
+	return globalRegistry.Get(defaultFilterName)
+}
+
+// handleNamedFilter routes /api/{name}, /api/{name}/add, /api/{name}/check,
+// /api/{name}/stats and /api/{name}/reset to the matching Registry
+// operation, mirroring the single-filter handlers but scoped to name.
+func handleNamedFilter(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/")
+	parts := strings.SplitN(path, "/", 2)
+	name := parts[0]
+	if name == "" {
+		http.Error(w, "filter name is required", http.StatusBadRequest)
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	if isMutatingNamedAction(r.Method, action) {
+		if _, ok := authorizeWrite(w, r); !ok {
+			return
+		}
+	} else if isReadNamedAction(action) {
+		if !authorizeRead(w, r) {
+			return
+		}
+	}
+
+	switch action {
+	case "":
+		handleNamedFilterRoot(w, r, name)
+	case "add":
+		handleNamedAdd(w, r, name)
+	case "check":
+		handleNamedCheck(w, r, name)
+	case "stats":
+		handleNamedStats(w, r, name)
+	case "reset":
+		handleNamedReset(w, r, name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// isMutatingNamedAction reports whether method/action mutates filter state,
+// and so must be routed through authorizeWrite before it runs: creating or
+// deleting a named filter (root, POST/DELETE), or adding to / resetting one.
+func isMutatingNamedAction(method, action string) bool {
+	switch action {
+	case "":
+		return method == http.MethodPost || method == http.MethodDelete
+	case "add", "reset":
+		return true
+	default:
+		return false
+	}
+}
+
+// isReadNamedAction reports whether action is a read-only named-filter
+// operation, and so must be routed through authorizeRead before it runs:
+// checking membership or reading stats.
+func isReadNamedAction(action string) bool {
+	switch action {
+	case "check", "stats":
+		return true
+	default:
+		return false
+	}
+}
+
+// handleNamedFilterRoot handles POST /api/{name} (create) and DELETE
+// /api/{name} (remove).
+func handleNamedFilterRoot(w http.ResponseWriter, r *http.Request, name string) {
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			ExpectedItems     int     `json:"expectedItems"`
+			FalsePositiveRate float64 `json:"falsePositiveRate"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if req.ExpectedItems <= 0 {
+			http.Error(w, "expectedItems must be greater than 0", http.StatusBadRequest)
+			return
+		}
+		if req.FalsePositiveRate <= 0 || req.FalsePositiveRate >= 1 {
+			http.Error(w, "falsePositiveRate must be between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		globalRegistry.Create(name, req.ExpectedItems, req.FalsePositiveRate)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	case http.MethodDelete:
+		if name == defaultFilterName {
+			http.Error(w, "cannot delete the default filter", http.StatusBadRequest)
+			return
+		}
+		globalRegistry.Delete(name)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleNamedAdd(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bf := globalRegistry.Get(name)
+	if bf == nil {
+		http.Error(w, "no such filter", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Item string `json:"item"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	bf.Add(req.Item)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+func handleNamedCheck(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bf := globalRegistry.Get(name)
+	if bf == nil {
+		http.Error(w, "no such filter", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Item string `json:"item"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"exists": bf.Contains(req.Item)})
+}
+
+func handleNamedStats(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bf := globalRegistry.Get(name)
+	if bf == nil {
+		http.Error(w, "no such filter", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bf.Stats())
+}
+
+func handleNamedReset(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bf := globalRegistry.Get(name)
+	if bf == nil {
+		http.Error(w, "no such filter", http.StatusNotFound)
+		return
+	}
+
+	bf.Reset()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// handleIndex handles GET /index and GET /index/{prefix}, returning the
+// names of registered filters matching prefix, mirroring the keepstore
+// index-by-prefix pattern.
+func handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	prefix := strings.TrimPrefix(r.URL.Path, "/index")
+	prefix = strings.TrimPrefix(prefix, "/")
+
+	names := globalRegistry.Names(prefix)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"names": names})
+}
@@ -0,0 +1,95 @@
+package main
+
+import "testing"
+
+func TestRegistryCreateGetDelete(t *testing.T) {
+	reg := NewRegistry()
+
+	if reg.Get("users") != nil {
+		t.Fatal("expected no filter before Create")
+	}
+
+	reg.Create("users", 100, 0.01)
+	bf := reg.Get("users")
+	if bf == nil {
+		t.Fatal("expected Create to register a filter")
+	}
+	bf.Add("alice")
+	if !bf.Contains("alice") {
+		t.Fatal("filter returned by Get should be the same instance that was added to")
+	}
+
+	reg.Delete("users")
+	if reg.Get("users") != nil {
+		t.Fatal("expected Get to return nil after Delete")
+	}
+
+	// Delete of a name that was never created is a no-op, not an error.
+	reg.Delete("never-existed")
+}
+
+func TestRegistryCreateReplacesExisting(t *testing.T) {
+	reg := NewRegistry()
+	reg.Create("users", 100, 0.01)
+	reg.Get("users").Add("alice")
+
+	reg.Create("users", 100, 0.01)
+	if reg.Get("users").Contains("alice") {
+		t.Fatal("expected Create to replace the existing filter, not reuse it")
+	}
+}
+
+func TestRegistryNamesFiltersByPrefix(t *testing.T) {
+	reg := NewRegistry()
+	reg.Create("prod-users", 100, 0.01)
+	reg.Create("prod-orders", 100, 0.01)
+	reg.Create("staging-users", 100, 0.01)
+
+	got := reg.Names("prod-")
+	if len(got) != 2 {
+		t.Fatalf("Names(prod-) = %v, want 2 names", got)
+	}
+
+	all := reg.Names("")
+	if len(all) != 3 {
+		t.Fatalf("Names(\"\") = %v, want 3 names", all)
+	}
+}
+
+func TestIsMutatingNamedAction(t *testing.T) {
+	cases := []struct {
+		method, action string
+		want           bool
+	}{
+		{"POST", "", true},
+		{"DELETE", "", true},
+		{"GET", "", false},
+		{"POST", "add", true},
+		{"POST", "reset", true},
+		{"POST", "check", false},
+		{"GET", "stats", false},
+	}
+	for _, c := range cases {
+		if got := isMutatingNamedAction(c.method, c.action); got != c.want {
+			t.Errorf("isMutatingNamedAction(%q, %q) = %v, want %v", c.method, c.action, got, c.want)
+		}
+	}
+}
+
+func TestIsReadNamedAction(t *testing.T) {
+	cases := []struct {
+		action string
+		want   bool
+	}{
+		{"check", true},
+		{"stats", true},
+		{"add", false},
+		{"reset", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := isReadNamedAction(c.action); got != c.want {
+			t.Errorf("isReadNamedAction(%q) = %v, want %v", c.action, got, c.want)
+		}
+	}
+}
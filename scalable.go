@@ -0,0 +1,157 @@
+package main
+
+import (
+	"math"
+	"sync"
+)
+
+// defaultFillRatioThreshold is the fill ratio at which a ScalableBloomFilter
+// appends a new stage rather than keep adding to the current (tail) one.
+const defaultFillRatioThreshold = 0.5
+
+// stage bundles a Bloom filter with the false-positive rate it was sized
+// for, so the compound FPR bound can be computed without re-deriving it from
+// m and k.
+type stage struct {
+	filter            *BloomFilter
+	falsePositiveRate float64
+	expectedItems     int
+}
+
+// ScalableBloomFilter wraps a growing slice of Bloom filter stages, adding a
+// new tightened stage whenever the current tail fills up past a threshold,
+// so the compound false-positive rate stays bounded without knowing the
+// total item count up front.
+type ScalableBloomFilter struct {
+	stages []stage
+	mu     sync.RWMutex
+
+	initialItems    int
+	fillRatioThresh float64
+	growthFactor    float64
+	tighteningRatio float64
+}
+
+// NewScalableBloomFilter creates a scalable Bloom filter whose first stage is
+// sized for initialItems at initialFPR. Each subsequent stage is sized
+// growthFactor times larger than the previous one's m, with a false positive
+// rate of previousFPR * tighteningRatio so the compound FPR converges.
+func NewScalableBloomFilter(initialItems int, initialFPR, growthFactor, tighteningRatio float64) *ScalableBloomFilter {
+	sbf := &ScalableBloomFilter{
+		initialItems:    initialItems,
+		fillRatioThresh: defaultFillRatioThreshold,
+		growthFactor:    growthFactor,
+		tighteningRatio: tighteningRatio,
+	}
+	sbf.stages = []stage{{
+		filter:            NewBloomFilter(initialItems, initialFPR),
+		falsePositiveRate: initialFPR,
+		expectedItems:     initialItems,
+	}}
+	return sbf
+}
+
+// Add inserts item into the current (tail) stage, growing a new stage first
+// if the tail's fill ratio has crossed the threshold.
+func (sbf *ScalableBloomFilter) Add(item string) {
+	sbf.mu.Lock()
+	defer sbf.mu.Unlock()
+
+	tail := &sbf.stages[len(sbf.stages)-1]
+	if fillRatio(tail.filter) >= sbf.fillRatioThresh {
+		sbf.growLocked()
+		tail = &sbf.stages[len(sbf.stages)-1]
+	}
+	tail.filter.Add(item)
+}
+
+// growLocked appends a new stage whose bit array is growthFactor times the
+// previous stage's m (up to rounding to a whole item count), at
+// previousFPR * tighteningRatio. Callers must hold sbf.mu.
+func (sbf *ScalableBloomFilter) growLocked() {
+	prev := sbf.stages[len(sbf.stages)-1]
+	nextFPR := prev.falsePositiveRate * sbf.tighteningRatio
+	// Invert NewBloomFilter's sizing formula to find the item count that
+	// yields m = growthFactor * prev.m at nextFPR.
+	nextM := float64(prev.filter.m) * sbf.growthFactor
+	nextItems := int(nextM * math.Ln2 * math.Ln2 / -math.Log(nextFPR))
+	if nextItems < 1 {
+		nextItems = 1
+	}
+
+	sbf.stages = append(sbf.stages, stage{
+		filter:            NewBloomFilter(nextItems, nextFPR),
+		falsePositiveRate: nextFPR,
+		expectedItems:     nextItems,
+	})
+}
+
+// Contains returns true if any stage reports a hit.
+func (sbf *ScalableBloomFilter) Contains(item string) bool {
+	sbf.mu.RLock()
+	defer sbf.mu.RUnlock()
+
+	for _, s := range sbf.stages {
+		if s.filter.Contains(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats reports per-stage size, item count, and the aggregate FPR bound
+// (the standard scalable Bloom filter bound: 1 minus the product of each
+// stage's complement, i.e. the probability that at least one stage false
+// positives).
+func (sbf *ScalableBloomFilter) Stats() map[string]interface{} {
+	sbf.mu.RLock()
+	defer sbf.mu.RUnlock()
+
+	stageStats := make([]map[string]interface{}, len(sbf.stages))
+	aggregateTrueNegative := 1.0
+	for i, s := range sbf.stages {
+		stageStats[i] = map[string]interface{}{
+			"size":              s.filter.m,
+			"hashFunctions":     s.filter.k,
+			"itemsAdded":        s.filter.items,
+			"fillRatio":         fillRatio(s.filter),
+			"falsePositiveRate": s.falsePositiveRate,
+		}
+		aggregateTrueNegative *= 1 - s.falsePositiveRate
+	}
+
+	return map[string]interface{}{
+		"stages":       stageStats,
+		"stageCount":   len(sbf.stages),
+		"aggregateFPR": 1 - aggregateTrueNegative,
+	}
+}
+
+// Reset discards all stages and starts over with a single initial-sized
+// stage, matching the sizing the filter was constructed with.
+func (sbf *ScalableBloomFilter) Reset() {
+	sbf.mu.Lock()
+	defer sbf.mu.Unlock()
+
+	initialFPR := sbf.stages[0].falsePositiveRate
+	sbf.stages = []stage{{
+		filter:            NewBloomFilter(sbf.initialItems, initialFPR),
+		falsePositiveRate: initialFPR,
+		expectedItems:     sbf.initialItems,
+	}}
+}
+
+// fillRatio computes a Bloom filter's current fill ratio under bf.mu's read
+// lock, without building the full Stats() map.
+func fillRatio(bf *BloomFilter) float64 {
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+
+	return float64(bf.countSetBits()) / float64(bf.m)
+}
+
+// Global scalable Bloom filter instance, using the typical scalable-bloom
+// growth policy: double in size each stage, tighten FPR by 0.8 each time.
+// It backs /api/add, /api/check, /api/stats and /api/reset when the server
+// is started with -mode=scalable.
+var globalScalableFilter = NewScalableBloomFilter(10000, 0.01, 2.0, 0.8)
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// snapshotVersion is the wire format version written by MarshalBinary.
+// Bumping it lets UnmarshalBinary reject snapshots from an incompatible
+// future format instead of misreading them.
+const snapshotVersion = 1
+
+// maxSnapshotBits and maxSnapshotHashes bound the m and k a restored/merged
+// snapshot may declare. They're generous relative to any filter this server
+// would realistically construct (NewBloomFilter(1e9, 1e-9) needs about
+// 4.3e10 bits, a little over 2^35, and 30 hash functions), but reject the absurd values a crafted
+// payload would otherwise get away with.
+const (
+	maxSnapshotBits   = 1 << 40
+	maxSnapshotHashes = 1024
+)
+
+// MarshalBinary serializes the filter's {m, k, items, bits} into a compact,
+// versioned binary format: a 1-byte version, then m, k, items and the word
+// count as little-endian uint64s, followed by the packed bit words
+// themselves. Because bits is already []uint64, the wire format matches the
+// in-memory layout exactly.
+func (bf *BloomFilter) MarshalBinary() ([]byte, error) {
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(snapshotVersion)
+	for _, v := range []uint64{uint64(bf.m), uint64(bf.k), uint64(bf.items), uint64(len(bf.bits))} {
+		if err := binary.Write(buf, binary.LittleEndian, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := binary.Write(buf, binary.LittleEndian, bf.bits); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the filter's state with the snapshot produced by
+// MarshalBinary.
+func (bf *BloomFilter) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+
+	var version uint8
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return err
+	}
+	if version != snapshotVersion {
+		return fmt.Errorf("bloomfilter: unsupported snapshot version %d", version)
+	}
+
+	var m, k, items, words uint64
+	for _, v := range []*uint64{&m, &k, &items, &words} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	// m and k are attacker-controlled and feed directly into wordsFor and
+	// getHashValues, so bound them to something no real filter would ever
+	// exceed before trusting them.
+	if m == 0 || m > maxSnapshotBits {
+		return fmt.Errorf("bloomfilter: snapshot declares out-of-range m=%d", m)
+	}
+	if k == 0 || k > maxSnapshotHashes {
+		return fmt.Errorf("bloomfilter: snapshot declares out-of-range k=%d", k)
+	}
+
+	// words is attacker-controlled (it comes straight off the wire), so
+	// check it against the bytes actually remaining before allocating —
+	// otherwise a crafted snapshot can make make() try to allocate
+	// terabytes and take down the process. It must also match wordsFor(m)
+	// exactly: a too-small words count with a matching m would otherwise be
+	// accepted, leaving bf.bits shorter than bf.m implies and panicking the
+	// next time Add/Contains/Merge indexes into it.
+	if words != uint64(wordsFor(uint(m))) {
+		return fmt.Errorf("bloomfilter: snapshot declares %d bit words but m=%d requires %d", words, m, wordsFor(uint(m)))
+	}
+	if words > uint64(r.Len())/8 {
+		return fmt.Errorf("bloomfilter: snapshot declares %d bit words but only %d bytes remain", words, r.Len())
+	}
+
+	bitWords := make([]uint64, words)
+	if err := binary.Read(r, binary.LittleEndian, bitWords); err != nil {
+		return err
+	}
+
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+
+	bf.m = uint(m)
+	bf.k = uint(k)
+	bf.items = int(items)
+	bf.bits = bitWords
+
+	return nil
+}
+
+// Restore atomically replaces bf's state with the snapshot in data.
+func (bf *BloomFilter) Restore(data []byte) error {
+	var decoded BloomFilter
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+
+	bf.m = decoded.m
+	bf.k = decoded.k
+	bf.items = decoded.items
+	bf.bits = decoded.bits
+
+	return nil
+}
+
+// Merge OR-combines the snapshot in data into bf, which is only valid when
+// both filters share the same m and k. This supports sharded ingestion
+// pipelines: each shard's filter can be snapshotted and merged into a
+// combined view.
+func (bf *BloomFilter) Merge(data []byte) error {
+	var other BloomFilter
+	if err := other.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+
+	if other.m != bf.m || other.k != bf.k {
+		return fmt.Errorf("bloomfilter: merge requires matching m and k (have m=%d k=%d, got m=%d k=%d)", bf.m, bf.k, other.m, other.k)
+	}
+
+	for i := range bf.bits {
+		bf.bits[i] |= other.bits[i]
+	}
+	bf.items += other.items
+
+	return nil
+}
+
+// handleSnapshot returns the current global filter as a binary blob, with a
+// SHA-256 checksum of the payload in the X-Checksum-Sha256 header.
+func handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	blob, err := defaultFilter().MarshalBinary()
+	if err != nil {
+		http.Error(w, "Failed to snapshot filter", http.StatusInternalServerError)
+		return
+	}
+	checksum := sha256.Sum256(blob)
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Checksum-Sha256", hex.EncodeToString(checksum[:]))
+	w.Write(blob)
+}
+
+// handleRestore replaces the global filter's state with the uploaded
+// snapshot, atomically under the filter's own lock.
+func handleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := defaultFilter().Restore(body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"success":true}`)
+}
+
+// handleMerge OR-combines an uploaded snapshot into the global filter,
+// rejecting it if its m/k don't match.
+func handleMerge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := defaultFilter().Merge(body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"success":true}`)
+}
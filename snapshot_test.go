@@ -0,0 +1,87 @@
+package main
+
+import "testing"
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	bf := NewBloomFilter(100, 0.01)
+	bf.Add("hello")
+	bf.Add("world")
+
+	blob, err := bf.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewBloomFilter(100, 0.01)
+	if err := restored.Restore(blob); err != nil {
+		t.Fatal(err)
+	}
+	if !restored.Contains("hello") || !restored.Contains("world") {
+		t.Fatal("restored filter is missing items that were snapshotted")
+	}
+}
+
+func TestMergeRequiresMatchingSize(t *testing.T) {
+	a := NewBloomFilter(100, 0.01)
+	a.Add("hello")
+	blob, err := a.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBloomFilter(200, 0.01)
+	if err := b.Merge(blob); err == nil {
+		t.Fatal("expected merge to fail when m/k differ")
+	}
+}
+
+func TestMergeOrCombinesBits(t *testing.T) {
+	a := NewBloomFilter(100, 0.01)
+	a.Add("hello")
+	blob, err := a.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBloomFilter(100, 0.01)
+	b.Add("world")
+	if err := b.Merge(blob); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Contains("hello") || !b.Contains("world") {
+		t.Fatal("merged filter is missing items from either source")
+	}
+}
+
+func TestUnmarshalBinaryRejectsOversizedWordCount(t *testing.T) {
+	bf := NewBloomFilter(100, 0.01)
+	blob, err := bf.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Overwrite the words field (the 4th uint64, right after the 1-byte
+	// version) with an enormous value with far more bytes than the payload
+	// actually carries.
+	const wordsOffset = 1 + 8*3
+	for i := 0; i < 8; i++ {
+		blob[wordsOffset+i] = 0xFF
+	}
+
+	if err := bf.UnmarshalBinary(blob); err == nil {
+		t.Fatal("expected UnmarshalBinary to reject an oversized word count")
+	}
+}
+
+func TestUnmarshalBinaryRejectsBadVersion(t *testing.T) {
+	bf := NewBloomFilter(100, 0.01)
+	blob, err := bf.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob[0] = snapshotVersion + 1
+
+	if err := bf.UnmarshalBinary(blob); err == nil {
+		t.Fatal("expected UnmarshalBinary to reject an unknown version")
+	}
+}